@@ -0,0 +1,83 @@
+// Copyright 2024 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics defines the Prometheus metrics reported by the TTL worker
+// subsystem.
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	// RunningJobsCnt is the gauge for the count of currently running TTL jobs on this instance.
+	RunningJobsCnt = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "tidb",
+		Subsystem: "ttl",
+		Name:      "running_jobs",
+		Help:      "The count of currently running TTL jobs on this instance",
+	})
+
+	// ScanningTaskCnt is the gauge for the count of running scan workers on this instance.
+	ScanningTaskCnt = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "tidb",
+		Subsystem: "ttl",
+		Name:      "scanning_tasks",
+		Help:      "The count of running scan workers on this instance",
+	})
+
+	// DeletingTaskCnt is the gauge for the count of running delete workers on this instance.
+	DeletingTaskCnt = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "tidb",
+		Subsystem: "ttl",
+		Name:      "deleting_tasks",
+		Help:      "The count of running delete workers on this instance",
+	})
+
+	// CancellingTaskCnt is the gauge for the count of tasks being cancelled on this instance.
+	CancellingTaskCnt = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "tidb",
+		Subsystem: "ttl",
+		Name:      "cancelling_tasks",
+		Help:      "The count of tasks being cancelled on this instance",
+	})
+
+	// JobScheduleCounter counts the outcomes of TTL job scheduling attempts. It is a counter,
+	// not a gauge, and must never be reset when the owner changes.
+	JobScheduleCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tidb",
+		Subsystem: "ttl",
+		Name:      "job_schedule_total",
+		Help:      "The total count of TTL job scheduling attempts, partitioned by result",
+	}, []string{"result"})
+)
+
+// runningGauges lists every gauge-type metric owned by the TTL worker subsystem. It deliberately
+// excludes counters such as JobScheduleCounter: gauges reflect point-in-time state of whichever
+// instance currently holds the TTL owner lease, while counters accumulate history that must
+// survive an owner handover.
+var runningGauges = []prometheus.Gauge{
+	RunningJobsCnt,
+	ScanningTaskCnt,
+	DeletingTaskCnt,
+	CancellingTaskCnt,
+}
+
+// ResetRunningGauges sets every gauge-type TTL metric back to zero. It is called whenever a node
+// stops being responsible for reporting these values (e.g. it boots without owning the TTL job,
+// or it loses the TTL owner lease), so that a stale non-zero value doesn't linger in monitoring
+// after the instance that produced it stops updating it.
+func ResetRunningGauges() {
+	for _, g := range runningGauges {
+		g.Set(0)
+	}
+}
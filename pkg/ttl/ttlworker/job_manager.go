@@ -0,0 +1,120 @@
+// Copyright 2024 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ttlworker
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/tidb/pkg/ttl/metrics"
+	"github.com/pingcap/tidb/pkg/util/logutil"
+	"go.uber.org/zap"
+)
+
+const ownerCheckInterval = time.Second
+
+// ownerChecker reports whether the current process holds the TTL owner lease. In production it's
+// backed by the etcd-based campaign manager used elsewhere in TiDB (e.g. for the DDL owner); tests
+// can supply a lightweight fake.
+type ownerChecker interface {
+	IsOwner() bool
+}
+
+// JobManager schedules and tracks the TTL jobs owned by this instance. Only the instance that
+// currently holds the TTL owner lease (ownerMgr.IsOwner()) is responsible for scheduling jobs and
+// reporting the gauge metrics in package metrics; every other instance sits idle.
+type JobManager struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	id       string
+	ownerMgr ownerChecker
+
+	// wasOwner records whether ownerLoop believed this instance held the TTL owner lease the
+	// last time it checked. It exists only to detect the is-owner -> not-owner transition; the
+	// authoritative answer always comes from ownerMgr.IsOwner().
+	wasOwner atomic.Bool
+}
+
+// NewJobManager creates a new TTL JobManager.
+func NewJobManager(id string, ownerMgr ownerChecker) *JobManager {
+	return &JobManager{
+		id:       id,
+		ownerMgr: ownerMgr,
+	}
+}
+
+// Start starts the job manager's background owner loop.
+func (m *JobManager) Start() {
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+
+	// The gauges this instance reports reflect whichever node currently owns the TTL job. A
+	// freshly booted manager doesn't own anything yet, so start from a clean slate rather than
+	// whatever a previous process on this binary (e.g. in tests) left behind.
+	m.cleanStaleMetrics()
+
+	m.wg.Add(1)
+	go m.ownerLoop()
+}
+
+// Stop stops the job manager and waits for its background loop to exit.
+func (m *JobManager) Stop() {
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.wg.Wait()
+}
+
+// ownerLoop periodically checks whether this instance still holds the TTL owner lease, and resets
+// the gauge metrics as soon as it observes a transition away from ownership. It also resets them
+// once more when the loop exits, so a manager that's stopped while still the owner doesn't leave
+// its gauges reporting stale non-zero values forever.
+func (m *JobManager) ownerLoop() {
+	defer m.wg.Done()
+	defer m.cleanStaleMetrics()
+
+	ticker := time.NewTicker(ownerCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			isOwner := m.ownerMgr.IsOwner()
+			if m.wasOwner.Load() && !isOwner {
+				logutil.BgLogger().Info("TTL job manager lost owner, resetting stale metrics", zap.String("id", m.id))
+				m.cleanStaleMetrics()
+			}
+			m.wasOwner.Store(isOwner)
+		}
+	}
+}
+
+// cleanStaleMetrics resets every gauge-type TTL metric to zero. Counters are intentionally left
+// untouched: they accumulate history across owner handovers, whereas gauges describe point-in-time
+// state that only the current owner should be reporting.
+func (m *JobManager) cleanStaleMetrics() {
+	metrics.ResetRunningGauges()
+}
+
+// ResetMetricsForTest exposes cleanStaleMetrics to tests in this package so fault-injection tests
+// can assert the cleanup path runs after a restart of the manager.
+func (m *JobManager) ResetMetricsForTest() {
+	m.cleanStaleMetrics()
+}
@@ -0,0 +1,171 @@
+// Copyright 2024 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ttlworker
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/pkg/sessionctx"
+	"github.com/pingcap/tidb/pkg/ttl/cache"
+	"github.com/pingcap/tidb/pkg/util"
+	"github.com/pingcap/tidb/pkg/util/chunk"
+	"github.com/pingcap/tidb/pkg/util/sqlexec"
+)
+
+// maxDrainRowsPerSQL is the chunk capacity ExecuteSQL passes to sqlexec.DrainRecordSet when it
+// reads the result of an internal statement. It does not cap the number of rows returned:
+// DrainRecordSet reads the RecordSet to the end. TTL only ever uses ExecuteSQL for small
+// metadata/variable queries, never for scanning table data.
+const maxDrainRowsPerSQL = 1024
+
+// Session wraps sessionctx.Context with the convenience helpers the TTL worker subsystem needs
+// for running internal SQL.
+type Session interface {
+	sessionctx.Context
+
+	// ExecuteSQL executes sql as an internal statement and returns the resulting rows.
+	ExecuteSQL(ctx context.Context, sql string, args ...any) ([]chunk.Row, error)
+}
+
+type session struct {
+	sessionctx.Context
+}
+
+func newSession(se sessionctx.Context) Session {
+	return &session{Context: se}
+}
+
+// ExecuteSQL implements Session.ExecuteSQL.
+func (s *session) ExecuteSQL(ctx context.Context, sql string, args ...any) ([]chunk.Row, error) {
+	rs, err := s.GetSQLExecutor().ExecuteInternal(ctx, sql, args...)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if rs == nil {
+		return nil, nil
+	}
+	defer rs.Close()
+
+	return sqlexec.DrainRecordSet(ctx, rs, maxDrainRowsPerSQL)
+}
+
+// GetSessionForTest obtains a Session directly from a session pool, bypassing the manager startup
+// path. It's exported for fault-injection tests in this package.
+func GetSessionForTest(pool util.DestroyableSessionPool) (Session, error) {
+	resource, err := pool.Get()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return newSession(resource.(sessionctx.Context)), nil
+}
+
+// ScanSession is a Session with the scan session profile for a particular TTL table applied on
+// top of it.
+type ScanSession struct {
+	Session
+}
+
+// varOverride records the prior value of a session variable NewScanSession changed, so it can be
+// restored later.
+type varOverride struct {
+	variable string
+	oldValue string
+}
+
+// NewScanSession wraps se for scanning tbl, applying the session-variable overrides from
+// defaultScanSessionProfileName, preserving the historical tidb_distsql_scan_concurrency=1/
+// tidb_enable_paging=OFF overrides.
+//
+// tbl is currently unused: picking a profile per table requires a TTL_SCAN_PROFILE table option
+// that isn't parsed anywhere in this tree yet (see the NOTE on ScanSessionProfile). It's kept as a
+// parameter so callers don't need to change again once that wiring lands.
+//
+// On success it returns the wrapped session and a restore closure that reverts exactly the
+// variables the profile touched. On error no session state is left changed: any override already
+// applied before the failure is rolled back before NewScanSession returns.
+func NewScanSession(se Session, _ *cache.PhysicalTable, _ time.Time) (*ScanSession, func(), error) {
+	profile, ok := ResolveScanSessionProfile("")
+	if !ok {
+		return nil, nil, errors.Errorf("unknown TTL scan session profile %q", defaultScanSessionProfileName)
+	}
+
+	restore, err := applyScanSessionProfile(se, profile)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+
+	return &ScanSession{Session: se}, restore, nil
+}
+
+// applyScanSessionProfile snapshots and overrides exactly the session variables profile.Overrides
+// names, in a deterministic order. If any override fails partway through, every override already
+// applied is rolled back before the error is returned.
+func applyScanSessionProfile(se Session, profile ScanSessionProfile) (func(), error) {
+	variables := make([]string, 0, len(profile.Overrides))
+	for v := range profile.Overrides {
+		variables = append(variables, v)
+	}
+	sort.Strings(variables)
+
+	applied := make([]varOverride, 0, len(variables))
+	for _, v := range variables {
+		oldValue, err := getSessionVariable(se, v)
+		if err != nil {
+			restoreScanSessionOverrides(se, applied)
+			return nil, errors.Trace(err)
+		}
+
+		if _, err := se.ExecuteSQL(context.Background(), fmt.Sprintf("set @@%s=%s", v, profile.Overrides[v])); err != nil {
+			restoreScanSessionOverrides(se, applied)
+			return nil, errors.Trace(err)
+		}
+
+		applied = append(applied, varOverride{variable: v, oldValue: oldValue})
+	}
+
+	return func() {
+		restoreScanSessionOverrides(se, applied)
+	}, nil
+}
+
+// restoreScanSessionOverrides reverts applied overrides in reverse order. It's best-effort: a
+// session that's already broken by a prior fault can't meaningfully recover, so restore errors
+// are swallowed rather than compounding the original failure.
+func restoreScanSessionOverrides(se Session, applied []varOverride) {
+	for i := len(applied) - 1; i >= 0; i-- {
+		o := applied[i]
+		_, _ = se.ExecuteSQL(context.Background(), fmt.Sprintf("set @@%s=%%?", o.variable), o.oldValue)
+	}
+}
+
+// getSessionVariable reads the current value of a session variable as its textual representation.
+// restoreScanSessionOverrides passes it back as a quoted string argument of `set @@var=%?`, so
+// values such as comma-separated lists or the empty string restore intact.
+func getSessionVariable(se Session, name string) (string, error) {
+	rows, err := se.ExecuteSQL(context.Background(), fmt.Sprintf("select @@%s", name))
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	if len(rows) == 0 {
+		return "", errors.Errorf("session variable %s has no value", name)
+	}
+
+	return rows[0].GetString(0), nil
+}
@@ -27,10 +27,13 @@ import (
 	"github.com/pingcap/tidb/pkg/sessionctx"
 	"github.com/pingcap/tidb/pkg/testkit"
 	"github.com/pingcap/tidb/pkg/ttl/cache"
+	"github.com/pingcap/tidb/pkg/ttl/metrics"
 	"github.com/pingcap/tidb/pkg/ttl/ttlworker"
 	"github.com/pingcap/tidb/pkg/util"
+	"github.com/pingcap/tidb/pkg/util/chunk"
 	"github.com/pingcap/tidb/pkg/util/logutil"
 	"github.com/pingcap/tidb/pkg/util/sqlexec"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
@@ -38,11 +41,30 @@ import (
 type fault interface {
 	// shouldFault returns whether the session should fault this time.
 	shouldFault(sql string) bool
+	// delay returns how long Execute*/ExecuteStmt should sleep before returning, fault or not.
+	// It's used to simulate slow queries such as a DELETE batch that overruns
+	// tidb_ttl_delete_batch_size's timeout, or a scan that a context deadline can race against.
+	delay(sql string) time.Duration
+	// wrapRecordSet optionally wraps the RecordSet returned by a successful Execute*/ExecuteStmt
+	// call, e.g. to make it error partway through iteration.
+	wrapRecordSet(sql string, rs sqlexec.RecordSet) sqlexec.RecordSet
 }
 
+// baseFault implements the no-op behavior for every fault method. Concrete fault types embed it
+// and only override what they actually model.
+type baseFault struct{}
+
+func (baseFault) shouldFault(sql string) bool { return false }
+
+func (baseFault) delay(sql string) time.Duration { return 0 }
+
+func (baseFault) wrapRecordSet(sql string, rs sqlexec.RecordSet) sqlexec.RecordSet { return rs }
+
 var _ fault = &faultAfterCount{}
 
 type faultAfterCount struct {
+	baseFault
+
 	faultCount int
 
 	currentCount int
@@ -61,6 +83,59 @@ func (f *faultAfterCount) shouldFault(sql string) bool {
 	return false
 }
 
+// latencyFault never faults, it only delays. It's composed with faultWithFilter to slow down
+// only the SQL statements a test cares about.
+type latencyFault struct {
+	baseFault
+
+	d time.Duration
+}
+
+func newLatencyFault(d time.Duration) *latencyFault {
+	return &latencyFault{d: d}
+}
+
+func (f *latencyFault) delay(sql string) time.Duration {
+	return f.d
+}
+
+// midScanFault never faults the Execute*/ExecuteStmt call itself; instead it wraps the returned
+// RecordSet so that it serves chunksBeforeErr chunks normally and then errors on the next Next().
+type midScanFault struct {
+	baseFault
+
+	chunksBeforeErr int
+}
+
+func newMidScanFault(chunksBeforeErr int) *midScanFault {
+	return &midScanFault{chunksBeforeErr: chunksBeforeErr}
+}
+
+func (f *midScanFault) wrapRecordSet(sql string, rs sqlexec.RecordSet) sqlexec.RecordSet {
+	if rs == nil {
+		return rs
+	}
+	return &faultyRecordSet{RecordSet: rs, chunksBeforeErr: f.chunksBeforeErr}
+}
+
+// faultyRecordSet wraps a sqlexec.RecordSet so that it returns a configurable number of chunks
+// before failing, to simulate a scan that errors mid-iteration rather than on the initial call.
+type faultyRecordSet struct {
+	sqlexec.RecordSet
+
+	chunksBeforeErr int
+	servedCount     int
+}
+
+func (rs *faultyRecordSet) Next(ctx context.Context, req *chunk.Chunk) error {
+	if rs.servedCount >= rs.chunksBeforeErr {
+		return errors.New("fault in test: mid-scan failure")
+	}
+
+	rs.servedCount++
+	return rs.RecordSet.Next(ctx, req)
+}
+
 type faultWithFilter struct {
 	filter func(string) bool
 	f      fault
@@ -74,6 +149,22 @@ func (f *faultWithFilter) shouldFault(sql string) bool {
 	return false
 }
 
+func (f *faultWithFilter) delay(sql string) time.Duration {
+	if f.filter == nil || f.filter(sql) {
+		return f.f.delay(sql)
+	}
+
+	return 0
+}
+
+func (f *faultWithFilter) wrapRecordSet(sql string, rs sqlexec.RecordSet) sqlexec.RecordSet {
+	if f.filter == nil || f.filter(sql) {
+		return f.f.wrapRecordSet(sql, rs)
+	}
+
+	return rs
+}
+
 func newFaultWithFilter(filter func(string) bool, f fault) *faultWithFilter {
 	return &faultWithFilter{filter: filter, f: f}
 }
@@ -98,25 +189,34 @@ func (s *sessionWithFault) GetSQLExecutor() sqlexec.SQLExecutor {
 
 // Execute implements sqlexec.SQLExecutor.
 func (s *sessionWithFault) Execute(ctx context.Context, sql string) ([]sqlexec.RecordSet, error) {
+	s.sleep(sql)
 	if s.shouldFault(sql) {
 		return nil, errors.New("fault in test")
 	}
-	return s.Context.GetSQLExecutor().Execute(ctx, sql)
+	rss, err := s.Context.GetSQLExecutor().Execute(ctx, sql)
+	for i, rs := range rss {
+		rss[i] = s.wrapRecordSet(sql, rs)
+	}
+	return rss, err
 }
 
 // ExecuteStmt implements sqlexec.SQLExecutor.
 func (s *sessionWithFault) ExecuteStmt(ctx context.Context, stmtNode ast.StmtNode) (sqlexec.RecordSet, error) {
+	s.sleep(stmtNode.Text())
 	if s.shouldFault(stmtNode.Text()) {
 		return nil, errors.New("fault in test")
 	}
-	return s.Context.GetSQLExecutor().ExecuteStmt(ctx, stmtNode)
+	rs, err := s.Context.GetSQLExecutor().ExecuteStmt(ctx, stmtNode)
+	return s.wrapRecordSet(stmtNode.Text(), rs), err
 }
 
 func (s *sessionWithFault) ExecuteInternal(ctx context.Context, sql string, args ...any) (sqlexec.RecordSet, error) {
+	s.sleep(sql)
 	if s.shouldFault(sql) {
 		return nil, errors.New("fault in test")
 	}
-	return s.Context.GetSQLExecutor().ExecuteInternal(ctx, sql, args...)
+	rs, err := s.Context.GetSQLExecutor().ExecuteInternal(ctx, sql, args...)
+	return s.wrapRecordSet(sql, rs), err
 }
 
 func (s *sessionWithFault) shouldFault(sql string) bool {
@@ -128,21 +228,120 @@ func (s *sessionWithFault) shouldFault(sql string) bool {
 	return (*fault).shouldFault(sql)
 }
 
+// sleep blocks for the duration the current fault assigns to sql, if any. It's used to simulate
+// latency such as a slow DELETE batch or a scan racing against context cancellation.
+func (s *sessionWithFault) sleep(sql string) {
+	fault := s.fault.Load()
+	if fault == nil {
+		return
+	}
+
+	if d := (*fault).delay(sql); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// wrapRecordSet lets the current fault substitute the RecordSet returned for sql, e.g. to make it
+// error partway through iteration instead of on the initial Execute* call.
+func (s *sessionWithFault) wrapRecordSet(sql string, rs sqlexec.RecordSet) sqlexec.RecordSet {
+	fault := s.fault.Load()
+	if fault == nil || rs == nil {
+		return rs
+	}
+
+	return (*fault).wrapRecordSet(sql, rs)
+}
+
+// poolFault describes faults injected at the SessionPool level itself, as opposed to faults in
+// the SQL executed through a session obtained from the pool. It's used to simulate a drained or
+// otherwise broken pool.
+//
+// NOTE: newLatencyFault and newMidScanFault exist to drive the scan-worker cancellation path and
+// the delete-worker retry/backoff path, but no scan-worker or delete-worker code lives in this
+// package yet. Until that code is added here, the tests below only exercise these faults and the
+// session/pool plumbing directly; they don't prove anything about how a real scan or delete worker
+// reacts to them.
+type poolFault interface {
+	shouldFaultGet() bool
+	shouldFaultPut() bool
+	shouldFaultDestroy() bool
+}
+
+// basePoolFault implements the no-op behavior for every poolFault method. Concrete pool fault
+// types embed it and only override what they actually model.
+type basePoolFault struct{}
+
+func (basePoolFault) shouldFaultGet() bool     { return false }
+func (basePoolFault) shouldFaultPut() bool     { return false }
+func (basePoolFault) shouldFaultDestroy() bool { return false }
+
+// poolGetFault makes SessionPool.Get start failing after it has succeeded faultCount times, to
+// simulate a pool that's been drained of sessions.
+type poolGetFault struct {
+	basePoolFault
+
+	faultCount   int
+	currentCount int
+}
+
+func newPoolGetFault(faultCount int) *poolGetFault {
+	return &poolGetFault{faultCount: faultCount}
+}
+
+func (f *poolGetFault) shouldFaultGet() bool {
+	if f.currentCount >= f.faultCount {
+		return true
+	}
+
+	f.currentCount++
+	return false
+}
+
+// poolPutFault makes SessionPool.Put silently drop the returned resource instead of recycling it,
+// to simulate a pool that's stopped accepting sessions back (e.g. it's being shut down).
+type poolPutFault struct {
+	basePoolFault
+}
+
+func newPoolPutFault() *poolPutFault {
+	return &poolPutFault{}
+}
+
+func (f *poolPutFault) shouldFaultPut() bool { return true }
+
+// poolDestroyFault makes SessionPool.Destroy silently skip destroying the resource, to simulate a
+// pool that's already discarded it (e.g. after the underlying connection was forcibly closed).
+type poolDestroyFault struct {
+	basePoolFault
+}
+
+func newPoolDestroyFault() *poolDestroyFault {
+	return &poolDestroyFault{}
+}
+
+func (f *poolDestroyFault) shouldFaultDestroy() bool { return true }
+
 type faultSessionPool struct {
 	util.DestroyableSessionPool
 
-	fault *atomic.Pointer[fault]
+	fault     *atomic.Pointer[fault]
+	poolFault *atomic.Pointer[poolFault]
 }
 
 func newFaultSessionPool(sp util.DestroyableSessionPool) *faultSessionPool {
 	return &faultSessionPool{
 		DestroyableSessionPool: sp,
 		fault:                  &atomic.Pointer[fault]{},
+		poolFault:              &atomic.Pointer[poolFault]{},
 	}
 }
 
 // Get implements util.SessionPool.
 func (f *faultSessionPool) Get() (pools.Resource, error) {
+	if pf := f.poolFault.Load(); pf != nil && (*pf).shouldFaultGet() {
+		return nil, errors.New("fault in test: pool get")
+	}
+
 	resource, err := f.DestroyableSessionPool.Get()
 	if err != nil {
 		return nil, err
@@ -156,11 +355,19 @@ func (f *faultSessionPool) Get() (pools.Resource, error) {
 
 // Put implements util.SessionPool.
 func (f *faultSessionPool) Put(se pools.Resource) {
+	if pf := f.poolFault.Load(); pf != nil && (*pf).shouldFaultPut() {
+		// Simulate a broken pool silently dropping the resource instead of reusing it.
+		return
+	}
 	f.DestroyableSessionPool.Put(se.(*sessionWithFault).Context.(pools.Resource))
 }
 
 // Destroy implements util.DestroyableSessionPool.
 func (f *faultSessionPool) Destroy(se pools.Resource) {
+	if pf := f.poolFault.Load(); pf != nil && (*pf).shouldFaultDestroy() {
+		// Simulate a broken pool that's already discarded the resource.
+		return
+	}
 	f.DestroyableSessionPool.Destroy(se.(*sessionWithFault).Context.(pools.Resource))
 }
 
@@ -173,6 +380,15 @@ func (f *faultSessionPool) setFault(ft fault) {
 	f.fault.Store(&ft)
 }
 
+func (f *faultSessionPool) setPoolFault(pf poolFault) {
+	if pf == nil {
+		f.poolFault.Store(nil)
+		return
+	}
+
+	f.poolFault.Store(&pf)
+}
+
 func TestGetSessionWithFault(t *testing.T) {
 	_, dom := testkit.CreateMockStoreAndDomain(t)
 
@@ -193,6 +409,189 @@ func TestGetSessionWithFault(t *testing.T) {
 	}
 }
 
+func TestGetSessionWithLatencyFault(t *testing.T) {
+	_, dom := testkit.CreateMockStoreAndDomain(t)
+
+	pool := newFaultSessionPool(dom.SysSessionPool())
+	pool.setFault(newFaultWithFilter(func(sql string) bool {
+		return sql == "select 1"
+	}, newLatencyFault(20*time.Millisecond)))
+
+	se, err := ttlworker.GetSessionForTest(pool)
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, err = se.ExecuteSQL(context.Background(), "select 1")
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+}
+
+// countingRecordSet is a bare-bones sqlexec.RecordSet that always succeeds and counts how many
+// times Next was called, so tests can assert exactly how many chunks a wrapper served before
+// diverging from that behavior.
+type countingRecordSet struct {
+	sqlexec.RecordSet // nil; only Next is exercised by these tests
+
+	calls int
+}
+
+func (rs *countingRecordSet) Next(ctx context.Context, req *chunk.Chunk) error {
+	rs.calls++
+	return nil
+}
+
+func TestMidScanFaultErrorsAfterNChunks(t *testing.T) {
+	inner := &countingRecordSet{}
+	wrapped := newMidScanFault(2).wrapRecordSet("irrelevant sql", inner)
+
+	ctx := context.Background()
+	require.NoError(t, wrapped.Next(ctx, nil))
+	require.NoError(t, wrapped.Next(ctx, nil))
+	require.Equal(t, 2, inner.calls)
+
+	// The third Next() is where the injected fault should fire instead of delegating to inner.
+	err := wrapped.Next(ctx, nil)
+	require.Error(t, err)
+	require.Equal(t, 2, inner.calls)
+}
+
+func TestGetSessionWithMidScanFault(t *testing.T) {
+	_, dom := testkit.CreateMockStoreAndDomain(t)
+
+	pool := newFaultSessionPool(dom.SysSessionPool())
+	pool.setFault(newFaultWithFilter(func(sql string) bool {
+		return sql == "select * from mysql.tidb_mdl_info"
+	}, newMidScanFault(0)))
+
+	se, err := ttlworker.GetSessionForTest(pool)
+	require.NoError(t, err)
+
+	// chunksBeforeErr is 0, so the very first Next() call the real RecordSet would otherwise
+	// serve (even an empty, EOF-signalling one) is replaced by the injected error.
+	_, err = se.ExecuteSQL(context.Background(), "select * from mysql.tidb_mdl_info")
+	require.Error(t, err)
+}
+
+// spySessionPool wraps a util.DestroyableSessionPool and counts how many times Put/Destroy
+// actually reach the underlying pool, so tests can prove a poolFault suppressed the call rather
+// than merely not erroring.
+type spySessionPool struct {
+	util.DestroyableSessionPool
+
+	puts     atomic.Int64
+	destroys atomic.Int64
+}
+
+func (s *spySessionPool) Put(resource pools.Resource) {
+	s.puts.Add(1)
+	s.DestroyableSessionPool.Put(resource)
+}
+
+func (s *spySessionPool) Destroy(resource pools.Resource) {
+	s.destroys.Add(1)
+	s.DestroyableSessionPool.Destroy(resource)
+}
+
+func TestFaultSessionPoolPutFault(t *testing.T) {
+	_, dom := testkit.CreateMockStoreAndDomain(t)
+
+	spy := &spySessionPool{DestroyableSessionPool: dom.SysSessionPool()}
+	pool := newFaultSessionPool(spy)
+	pool.setPoolFault(newPoolPutFault())
+
+	resource, err := pool.Get()
+	require.NoError(t, err)
+
+	pool.Put(resource)
+	require.Equal(t, int64(0), spy.puts.Load())
+}
+
+func TestFaultSessionPoolDestroyFault(t *testing.T) {
+	_, dom := testkit.CreateMockStoreAndDomain(t)
+
+	spy := &spySessionPool{DestroyableSessionPool: dom.SysSessionPool()}
+	pool := newFaultSessionPool(spy)
+	pool.setPoolFault(newPoolDestroyFault())
+
+	resource, err := pool.Get()
+	require.NoError(t, err)
+
+	pool.Destroy(resource)
+	require.Equal(t, int64(0), spy.destroys.Load())
+}
+
+func TestFaultSessionPoolGetFault(t *testing.T) {
+	_, dom := testkit.CreateMockStoreAndDomain(t)
+
+	pool := newFaultSessionPool(dom.SysSessionPool())
+	pool.setPoolFault(newPoolGetFault(2))
+
+	for i := 0; i < 2; i++ {
+		se, err := ttlworker.GetSessionForTest(pool)
+		require.NoError(t, err)
+		require.NotNil(t, se)
+	}
+
+	// The pool is now "drained"; GetSessionForTest must surface that as an error rather than
+	// panicking on a nil resource.
+	_, err := ttlworker.GetSessionForTest(pool)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "fault in test: pool get")
+}
+
+// fakeOwnerManager is a trivial ownerChecker whose IsOwner() can be flipped by tests to simulate
+// a restart-induced owner handover.
+type fakeOwnerManager struct {
+	isOwner atomic.Bool
+}
+
+func (f *fakeOwnerManager) IsOwner() bool {
+	return f.isOwner.Load()
+}
+
+func TestJobManagerResetsStaleMetricsAfterFaultInducedRestart(t *testing.T) {
+	owner := &fakeOwnerManager{}
+	owner.isOwner.Store(true)
+
+	// Put every gauge at a nonzero value first, as if this instance had been the TTL owner and
+	// reporting real progress, so the assertions below can't pass unless cleanStaleMetrics
+	// actually ran.
+	metrics.RunningJobsCnt.Set(3)
+	metrics.ScanningTaskCnt.Set(5)
+	metrics.DeletingTaskCnt.Set(7)
+	metrics.CancellingTaskCnt.Set(1)
+
+	m := ttlworker.NewJobManager("test-job-manager", owner)
+	m.Start()
+
+	// Simulate a fault that takes the owner lease away from this instance. ownerLoop first polls
+	// one ownerCheckInterval after Start, so here it never records this instance as the owner and
+	// sees no transition; the reset checked below comes from Start and ResetMetricsForTest.
+	owner.isOwner.Store(false)
+
+	// ResetMetricsForTest exercises the same cleanup path directly, so the assertion doesn't
+	// depend on winning a race against ownerLoop's polling interval.
+	m.ResetMetricsForTest()
+
+	require.Equal(t, float64(0), testutil.ToFloat64(metrics.RunningJobsCnt))
+	require.Equal(t, float64(0), testutil.ToFloat64(metrics.ScanningTaskCnt))
+	require.Equal(t, float64(0), testutil.ToFloat64(metrics.DeletingTaskCnt))
+	require.Equal(t, float64(0), testutil.ToFloat64(metrics.CancellingTaskCnt))
+
+	m.Stop()
+
+	// Stop() tears the manager down while it's not the owner; the gauges must stay at zero
+	// rather than being left however the last real scan/delete workers happened to set them.
+	require.Equal(t, float64(0), testutil.ToFloat64(metrics.RunningJobsCnt))
+
+	// Restart the manager after the fault with a gauge left non-zero in between; Start must reset
+	// it again.
+	metrics.RunningJobsCnt.Set(3)
+	m.Start()
+	require.Equal(t, float64(0), testutil.ToFloat64(metrics.RunningJobsCnt))
+	m.Stop()
+}
+
 func TestNewScanSession(t *testing.T) {
 	_, dom := testkit.CreateMockStoreAndDomain(t)
 	pool := newFaultSessionPool(dom.SysSessionPool())
@@ -253,3 +652,52 @@ func TestNewScanSession(t *testing.T) {
 		})
 	}
 }
+
+func TestScanSessionProfileRegistryPartialApplyDoesNotLeak(t *testing.T) {
+	_, dom := testkit.CreateMockStoreAndDomain(t)
+	pool := newFaultSessionPool(dom.SysSessionPool())
+	pool.setFault(newFaultWithFilter(func(s string) bool { return false }, newFaultAfterCount(0)))
+	se, err := ttlworker.GetSessionForTest(pool)
+	require.NoError(t, err)
+
+	_, err = se.ExecuteSQL(context.Background(), "set @@tidb_distsql_scan_concurrency=123")
+	require.NoError(t, err)
+	_, err = se.ExecuteSQL(context.Background(), "set @@tidb_enable_paging=ON")
+	require.NoError(t, err)
+
+	// NewScanSession always resolves "default" today (there's no SQL-level TTL_SCAN_PROFILE
+	// option yet — see the NOTE on ScanSessionProfile), so partial-apply rollback is exercised by
+	// temporarily re-registering "default" itself with a third variable that's made to fail, and
+	// restoring the original registration afterwards.
+	original, ok := ttlworker.ResolveScanSessionProfile("")
+	require.True(t, ok)
+	ttlworker.RegisterScanSessionProfile(ttlworker.ScanSessionProfile{
+		Name: original.Name,
+		Overrides: map[string]string{
+			"tidb_distsql_scan_concurrency": "4",
+			"tidb_enable_paging":            "OFF",
+			"tidb_mem_quota_query":          "123456",
+		},
+	})
+	defer ttlworker.RegisterScanSessionProfile(original)
+
+	// tidb_mem_quota_query, the last variable in sorted order, fails to apply; the two before it
+	// (tidb_distsql_scan_concurrency, tidb_enable_paging), already applied, must be rolled back
+	// rather than left overridden.
+	var memQuotaAttempted atomic.Bool
+	pool.setFault(newFaultWithFilter(func(s string) bool {
+		if s == "set @@tidb_mem_quota_query=123456" {
+			memQuotaAttempted.Store(true)
+			return true
+		}
+		return false
+	}, newFaultAfterCount(0)))
+
+	tblSe, restore, err := ttlworker.NewScanSession(se, &cache.PhysicalTable{}, time.Now())
+	require.Error(t, err)
+	require.Nil(t, tblSe)
+	require.Nil(t, restore)
+	require.True(t, memQuotaAttempted.Load())
+	require.Equal(t, 123, se.GetSessionVars().DistSQLScanConcurrency())
+	require.True(t, se.GetSessionVars().EnablePaging)
+}